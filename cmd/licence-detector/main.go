@@ -0,0 +1,296 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Command licence-detector reports the licences used by a Go module's
+// dependencies.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"go.elastic.co/go-licence-detector/dependency"
+	"go.elastic.co/go-licence-detector/detector"
+	"go.elastic.co/go-licence-detector/sbom"
+	"go.elastic.co/go-licence-detector/spdx"
+)
+
+func main() {
+	var (
+		depsIn           = flag.String("in", "", "path to a `go list -m -json all` dependency list; ignored when -module or -workspace is set (defaults to stdin)")
+		modules          = flag.String("module", "", "comma-separated module roots to run `go list` in, instead of reading -in")
+		workspace        = flag.Bool("workspace", false, "discover every module in the go.work workspace rooted at -module (or the current directory)")
+		rulesFile        = flag.String("rules", "", "path to the licence rules file")
+		overridesFile    = flag.String("overrides", "", "path to a JSON file of per-module dependency.Info overrides")
+		noticeTemplate   = flag.String("noticeTemplate", "", "path to the Go template used to render the notice file")
+		noticeOut        = flag.String("noticeOut", "", "path to write the rendered notice file to")
+		cycloneDxOut     = flag.String("cycloneDx", "", "path to write a CycloneDX SBOM to")
+		spdxOut          = flag.String("spdx", "", "path to write an SPDX tag:value document to")
+		spdxJSONOut      = flag.String("spdxJson", "", "path to write an SPDX JSON document to")
+		includeIndirect  = flag.Bool("includeIndirect", false, "include indirect dependencies in the report")
+		customLicenceDir = flag.String("licenceArchive", "", "path to a custom licence archive for the classifier")
+	)
+	flag.Parse()
+
+	cfg := config{
+		depsIn:           *depsIn,
+		moduleRoots:      splitModuleRoots(*modules),
+		workspace:        *workspace,
+		rulesFile:        *rulesFile,
+		overridesFile:    *overridesFile,
+		noticeTemplate:   *noticeTemplate,
+		noticeOut:        *noticeOut,
+		cycloneDxOut:     *cycloneDxOut,
+		spdxOut:          *spdxOut,
+		spdxJSONOut:      *spdxJSONOut,
+		customLicenceDir: *customLicenceDir,
+		includeIndirect:  *includeIndirect,
+	}
+
+	if err := run(context.Background(), cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func splitModuleRoots(modules string) []string {
+	if modules == "" {
+		return nil
+	}
+	return strings.Split(modules, ",")
+}
+
+type config struct {
+	depsIn           string
+	moduleRoots      []string
+	workspace        bool
+	rulesFile        string
+	overridesFile    string
+	noticeTemplate   string
+	noticeOut        string
+	cycloneDxOut     string
+	spdxOut          string
+	spdxJSONOut      string
+	customLicenceDir string
+	includeIndirect  bool
+}
+
+func run(ctx context.Context, cfg config) error {
+	rules, err := detector.LoadRules(cfg.rulesFile)
+	if err != nil {
+		return err
+	}
+
+	overrides, err := loadOverrides(cfg.overridesFile)
+	if err != nil {
+		return err
+	}
+
+	classifier, err := detector.NewClassifier(cfg.customLicenceDir)
+	if err != nil {
+		return err
+	}
+
+	var deps *dependency.List
+	var rootModule dependency.Info
+
+	if cfg.workspace || len(cfg.moduleRoots) > 0 {
+		deps, err = detector.DetectModules(ctx, detector.DetectConfig{
+			ModuleRoots:     cfg.moduleRoots,
+			Workspace:       cfg.workspace,
+			IncludeIndirect: cfg.includeIndirect,
+			Classifier:      classifier,
+			Rules:           rules,
+			Overrides:       overrides,
+		})
+		if err != nil {
+			return err
+		}
+		rootModule, err = mainModule(ctx, firstOr(cfg.moduleRoots, "."))
+		if err != nil {
+			return err
+		}
+	} else {
+		raw, err := readDeps(cfg.depsIn)
+		if err != nil {
+			return err
+		}
+
+		deps, err = detector.Detect(bytes.NewReader(raw), classifier, rules, overrides, cfg.includeIndirect)
+		if err != nil {
+			return err
+		}
+
+		rootModule, err = findRootModule(raw)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.noticeTemplate != "" {
+		if err := renderNotice(cfg.noticeTemplate, cfg.noticeOut, deps); err != nil {
+			return err
+		}
+	}
+
+	if cfg.cycloneDxOut != "" || cfg.spdxOut != "" || cfg.spdxJSONOut != "" {
+		deps.Module = rootModule
+	}
+
+	if cfg.cycloneDxOut != "" {
+		if err := writeCycloneDX(cfg.cycloneDxOut, deps); err != nil {
+			return err
+		}
+	}
+
+	if cfg.spdxOut != "" {
+		if err := writeSPDX(cfg.spdxOut, deps, spdx.Options{Format: spdx.FormatTagValue}); err != nil {
+			return err
+		}
+	}
+
+	if cfg.spdxJSONOut != "" {
+		if err := writeSPDX(cfg.spdxJSONOut, deps, spdx.Options{Format: spdx.FormatJSON}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func firstOr(items []string, fallback string) string {
+	if len(items) == 0 {
+		return fallback
+	}
+	return items[0]
+}
+
+func readDeps(path string) ([]byte, error) {
+	if path == "" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// findRootModule picks out the module marked `"Main": true` in a
+// `go list -m -json all` stream, which Detect itself discards.
+func findRootModule(raw []byte) (dependency.Info, error) {
+	modules, err := detector.DecodeModules(bytes.NewReader(raw))
+	if err != nil {
+		return dependency.Info{}, err
+	}
+
+	for _, m := range modules {
+		if m.Main {
+			return dependency.Info{
+				Name:    m.Path,
+				Version: m.Version,
+				Dir:     m.Dir,
+			}, nil
+		}
+	}
+
+	return dependency.Info{}, nil
+}
+
+// mainModule runs `go list -m -json` (without "all") in root to find the
+// consuming module itself, which DetectModules does not return.
+func mainModule(ctx context.Context, root string) (dependency.Info, error) {
+	cmd := exec.CommandContext(ctx, "go", "list", "-mod=mod", "-json", "-m")
+	cmd.Dir = root
+	cmd.Env = os.Environ()
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return dependency.Info{}, err
+	}
+
+	var m detector.Module
+	if err := json.Unmarshal(out.Bytes(), &m); err != nil {
+		return dependency.Info{}, err
+	}
+
+	return dependency.Info{Name: m.Path, Version: m.Version, Dir: m.Dir}, nil
+}
+
+func loadOverrides(path string) (dependency.Overrides, error) {
+	overrides := dependency.Overrides{}
+	if path == "" {
+		return overrides, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&overrides); err != nil {
+		return nil, err
+	}
+
+	return overrides, nil
+}
+
+func renderNotice(templateFile, outFile string, deps *dependency.List) error {
+	tmpl, err := template.ParseFiles(templateFile)
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if outFile != "" {
+		f, err := os.Create(outFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	return tmpl.Execute(out, deps)
+}
+
+func writeCycloneDX(outFile string, deps *dependency.List) error {
+	f, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return sbom.WriteCycloneDX(f, deps)
+}
+
+func writeSPDX(outFile string, deps *dependency.List, opts spdx.Options) error {
+	f, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return spdx.WriteSPDX(f, deps, opts)
+}
@@ -0,0 +1,86 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package dependency defines the shapes used to describe a Go module's
+// dependency graph once licence information has been attached to it.
+package dependency
+
+import "strings"
+
+// Info describes a single dependency and the licence that was detected (or
+// supplied via an override) for it.
+type Info struct {
+	Name        string
+	Version     string
+	VersionTime string
+	Dir         string
+	LicenceType string
+	LicenceFile string
+	URL         string
+
+	// Licences holds every licence match the classifier found in
+	// LicenceFile above a confidence threshold, ranked highest confidence
+	// first. LicenceType mirrors Licences[0].SPDXID for dependencies with
+	// a single, unambiguous match. A module carrying more than one entry
+	// here is dual- (or multi-) licensed.
+	Licences []LicenceMatch
+
+	// LicenceText, when set on an override, supplies the raw licence body
+	// to classify for a module whose source tree ships no file Detect can
+	// recognise on its own.
+	LicenceText string
+
+	// ConfidenceFloor, when set on an override, raises the minimum
+	// classifier confidence required for this module's matches, to
+	// suppress low-confidence guesses that would otherwise still clear
+	// the package-wide default.
+	ConfidenceFloor float64
+}
+
+// LicenceMatch is a single licence identified within a licence file, along
+// with the classifier's confidence and the byte range it covered.
+type LicenceMatch struct {
+	SPDXID     string
+	Confidence float64
+	Offset     int
+	Extent     int
+}
+
+// List groups the dependencies of a module by whether they are imported
+// directly or pulled in transitively.
+type List struct {
+	Direct   []Info
+	Indirect []Info
+
+	// Module identifies the consuming module itself. Detect does not
+	// populate this field; callers that need it (e.g. the SBOM writers)
+	// fill it in from the same module list used to build Direct/Indirect.
+	Module Info
+}
+
+// LooksLikeSPDXID reports whether licenceType resembles an SPDX licence
+// identifier (e.g. "Apache-2.0") rather than free text pulled out of a
+// licence file (e.g. "Totally Legit License 2.0").
+func LooksLikeSPDXID(licenceType string) bool {
+	return licenceType != "" && !strings.ContainsAny(licenceType, " \t")
+}
+
+// Overrides holds manual corrections to apply on top of detected
+// dependency information, keyed by module path. A key ending in "/*" (e.g.
+// "k8s.io/*") instead supplies a default applied to every module under that
+// prefix which has no exact-match entry of its own.
+type Overrides map[string]Info
@@ -0,0 +1,644 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package detector turns the output of `go list -m -json` into a
+// dependency.List annotated with licence information, applying overrides
+// and rules along the way.
+package detector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/licenseclassifier"
+	"go.elastic.co/go-licence-detector/dependency"
+	"go.elastic.co/go-licence-detector/licensedb"
+)
+
+// ErrLicenceNotAllowed is returned by Detect when none of a dependency's
+// detected licences satisfy the configured Rules.
+var ErrLicenceNotAllowed = errors.New("licence not allowed")
+
+// defaultConfidenceThreshold is the minimum classifier confidence a match
+// must reach to be surfaced on dependency.Info at all. It is deliberately
+// higher than licenseclassifier.DefaultConfidenceThreshold, which is tuned
+// for "is there a licence here" rather than "can we trust this SPDX id".
+const defaultConfidenceThreshold = 0.9
+
+// licenceFileStems are the case-insensitive, extension-less file name stems
+// that are recognised as carrying a module's licence text.
+var licenceFileStems = []string{"licence", "license", "copying"}
+
+var githubPathPattern = regexp.MustCompile(`^(github\.com/[^/]+/[^/]+)`)
+
+// Module mirrors the subset of `go list -m -json` fields Detect needs.
+type Module struct {
+	Path     string
+	Version  string
+	Time     string
+	Dir      string
+	Indirect bool
+	Main     bool
+	Replace  *Module
+}
+
+// Classifier identifies the licence carried by a licence file's contents.
+type Classifier struct {
+	inner *licenseclassifier.License
+}
+
+// NewClassifier builds a Classifier. customArchive, when non-empty, points
+// at an alternative licence archive file on disk to load instead of the one
+// embedded in licensedb.
+//
+// The classifier is always seeded via licenseclassifier.ArchiveBytes rather
+// than licenseclassifier.Archive: the vendored google/licenseclassifier
+// resolves Archive paths through its own embedded licenses/*.txt corpus, so
+// it can never see a path on the real filesystem, custom or otherwise.
+func NewClassifier(customArchive string) (*Classifier, error) {
+	archive := licensedb.Archive()
+	if customArchive != "" {
+		content, err := os.ReadFile(customArchive)
+		if err != nil {
+			return nil, fmt.Errorf("reading custom licence archive: %w", err)
+		}
+		archive = content
+	}
+
+	inner, err := licenseclassifier.New(licenseclassifier.DefaultConfidenceThreshold, licenseclassifier.ArchiveBytes(archive))
+	if err != nil {
+		return nil, fmt.Errorf("creating licence classifier: %w", err)
+	}
+
+	return &Classifier{inner: inner}, nil
+}
+
+// IdentifyAll returns every non-overlapping licence match found in content
+// whose confidence reaches floor (or defaultConfidenceThreshold, if floor is
+// zero), ranked highest confidence first. A LICENSE file that concatenates
+// more than one licence (e.g. a dual "MIT OR Apache-2.0" notice) yields more
+// than one match, but repeated hits on the same SPDX id (e.g. an Apache-2.0
+// body followed by its "APPENDIX: How to apply" boilerplate) are coalesced
+// into the single highest-confidence match, since that's still one licence,
+// not a dual-licensed dependency. A nil, nil result means nothing reached
+// the threshold; the dependency is left for the caller to treat as
+// unresolved rather than as an error, since plenty of real licence files
+// legitimately confuse the classifier.
+func (c *Classifier) IdentifyAll(content []byte, floor float64) []dependency.LicenceMatch {
+	if floor <= 0 {
+		floor = defaultConfidenceThreshold
+	}
+
+	raw := c.inner.MultipleMatch(string(content), true)
+	sort.Slice(raw, func(i, j int) bool { return raw[i].Confidence > raw[j].Confidence })
+
+	var claimed [][2]int
+	seenSPDXIDs := map[string]bool{}
+	var matches []dependency.LicenceMatch
+	for _, m := range raw {
+		if m.Confidence < floor {
+			continue
+		}
+		start, end := m.Offset, m.Offset+m.Extent
+		if rangeClaimed(claimed, start, end) {
+			continue
+		}
+		if seenSPDXIDs[m.Name] {
+			continue
+		}
+
+		claimed = append(claimed, [2]int{start, end})
+		seenSPDXIDs[m.Name] = true
+		matches = append(matches, dependency.LicenceMatch{
+			SPDXID:     m.Name,
+			Confidence: m.Confidence,
+			Offset:     m.Offset,
+			Extent:     m.Extent,
+		})
+	}
+
+	return matches
+}
+
+// rangeClaimed reports whether [start, end) overlaps any byte range already
+// claimed by a higher-confidence match.
+func rangeClaimed(claimed [][2]int, start, end int) bool {
+	for _, c := range claimed {
+		if start < c[1] && end > c[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// Rules describes which licences are acceptable. A dependency satisfies the
+// rules if at least one of the matches reaching MinConfidence is both not
+// Denied and, when Allowed is non-empty, itself Allowed.
+type Rules struct {
+	Allowed       []string `json:"allowed"`
+	Denied        []string `json:"denied"`
+	MinConfidence float64  `json:"minConfidence"`
+}
+
+// LoadRules reads a Rules document from path.
+func LoadRules(path string) (*Rules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening rules file: %w", err)
+	}
+	defer f.Close()
+
+	var r Rules
+	if err := json.NewDecoder(f).Decode(&r); err != nil {
+		return nil, fmt.Errorf("parsing rules file: %w", err)
+	}
+
+	return &r, nil
+}
+
+// Validate returns ErrLicenceNotAllowed unless at least one eligible match
+// (reaching MinConfidence) both avoids Denied and, if Allowed is configured,
+// is on it. A dual-licensed dependency is only rejected if every eligible
+// match fails that test; one acceptable alternative is enough.
+func (r *Rules) Validate(matches []dependency.LicenceMatch) error {
+	eligible := matches
+	if r.MinConfidence > 0 {
+		eligible = nil
+		for _, m := range matches {
+			if m.Confidence >= r.MinConfidence {
+				eligible = append(eligible, m)
+			}
+		}
+	}
+
+	for _, m := range eligible {
+		if r.isDenied(m.SPDXID) {
+			continue
+		}
+		if len(r.Allowed) == 0 || r.isAllowed(m.SPDXID) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: no combination of %s satisfies the configured rules", ErrLicenceNotAllowed, licenceNames(matches))
+}
+
+func (r *Rules) isDenied(spdxID string) bool {
+	for _, denied := range r.Denied {
+		if strings.EqualFold(denied, spdxID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rules) isAllowed(spdxID string) bool {
+	for _, allowed := range r.Allowed {
+		if strings.EqualFold(allowed, spdxID) {
+			return true
+		}
+	}
+	return false
+}
+
+func licenceNames(matches []dependency.LicenceMatch) string {
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = m.SPDXID
+	}
+	return strings.Join(names, ", ")
+}
+
+// DetectConfig configures DetectModules.
+type DetectConfig struct {
+	// ModuleRoots are the directories `go list` is run from. Defaults to
+	// the current directory when empty and Workspace is false.
+	ModuleRoots []string
+	// Workspace, when true, ignores ModuleRoots beyond the first entry
+	// (used to locate go.work) and instead discovers every module listed
+	// in that workspace's `use` directives.
+	Workspace       bool
+	IncludeIndirect bool
+	Classifier      *Classifier
+	Rules           *Rules
+	Overrides       dependency.Overrides
+}
+
+// DetectModules drives detection directly from `go list -m -json all`
+// instead of a pre-generated deps.json file, optionally merging the
+// dependency graphs of every module in a Go workspace.
+func DetectModules(ctx context.Context, cfg DetectConfig) (*dependency.List, error) {
+	roots := cfg.ModuleRoots
+	if cfg.Workspace {
+		workspaceRoots, err := discoverWorkspaceModules(roots)
+		if err != nil {
+			return nil, fmt.Errorf("discovering workspace modules: %w", err)
+		}
+		roots = workspaceRoots
+	}
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	merged := map[string]Module{}
+	var order []string
+	for _, root := range roots {
+		modules, err := listModules(ctx, root)
+		if err != nil {
+			return nil, fmt.Errorf("listing modules in %s: %w", root, err)
+		}
+
+		for _, m := range modules {
+			if m.Main {
+				continue
+			}
+
+			existing, ok := merged[m.Path]
+			if !ok {
+				merged[m.Path] = m
+				order = append(order, m.Path)
+				continue
+			}
+
+			merged[m.Path] = mergeModule(existing, m)
+		}
+	}
+
+	modules := make([]Module, 0, len(order))
+	for _, path := range order {
+		modules = append(modules, merged[path])
+	}
+
+	return buildDependencyList(modules, cfg.Classifier, cfg.Rules, cfg.Overrides, cfg.IncludeIndirect)
+}
+
+// mergeModule folds m, a later workspace module's view of a dependency,
+// into existing, the view accumulated from earlier workspace modules: the
+// merged entry is Direct if either view is Direct, and takes on m's Replace
+// (and other fields) whenever m names one, without losing the Indirect
+// status just computed.
+func mergeModule(existing, m Module) Module {
+	if !m.Indirect {
+		existing.Indirect = false
+	}
+	if m.Replace != nil {
+		indirect := existing.Indirect
+		existing = m
+		existing.Indirect = indirect
+	}
+	return existing
+}
+
+// listModules runs `go list -m -json all` in root, respecting whatever
+// GOFLAGS, -mod=mod behaviour and build tags the caller's environment (or
+// GOWORK) already implies.
+func listModules(ctx context.Context, root string) ([]Module, error) {
+	cmd := exec.CommandContext(ctx, "go", "list", "-mod=mod", "-json", "-m", "all")
+	cmd.Dir = root
+	cmd.Env = os.Environ()
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("running go list -m -json all: %w", err)
+	}
+
+	return DecodeModules(&out)
+}
+
+// discoverWorkspaceModules finds every module listed in the `use`
+// directives of the go.work file rooted at (or pointed at by GOWORK from)
+// roots[0].
+func discoverWorkspaceModules(roots []string) ([]string, error) {
+	root := "."
+	if len(roots) > 0 {
+		root = roots[0]
+	}
+
+	workFile := os.Getenv("GOWORK")
+	if workFile == "" {
+		workFile = filepath.Join(root, "go.work")
+	}
+
+	content, err := os.ReadFile(workFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", workFile, err)
+	}
+
+	uses := parseWorkUse(content)
+	base := filepath.Dir(workFile)
+
+	modules := make([]string, 0, len(uses))
+	for _, use := range uses {
+		modules = append(modules, filepath.Join(base, use))
+	}
+
+	return modules, nil
+}
+
+// parseWorkUse extracts the directories named by `use` directives in a
+// go.work file, supporting both the single-line and parenthesised block
+// forms.
+func parseWorkUse(content []byte) []string {
+	var uses []string
+	inBlock := false
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		switch {
+		case inBlock:
+			if line == ")" {
+				inBlock = false
+				continue
+			}
+			uses = append(uses, strings.Fields(line)[0])
+		case line == "use (":
+			inBlock = true
+		case strings.HasPrefix(line, "use "):
+			uses = append(uses, strings.TrimSpace(strings.TrimPrefix(line, "use")))
+		}
+	}
+
+	return uses
+}
+
+// DecodeModules accepts either a JSON array of modules or the concatenated,
+// newline-separated JSON objects that `go list -m -json` streams.
+func DecodeModules(r io.Reader) ([]Module, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading dependency list: %w", err)
+	}
+
+	var modules []Module
+	if err := json.Unmarshal(raw, &modules); err == nil {
+		return modules, nil
+	}
+
+	modules = nil
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	for dec.More() {
+		var m Module
+		if err := dec.Decode(&m); err != nil {
+			return nil, fmt.Errorf("parsing dependency list: %w", err)
+		}
+		modules = append(modules, m)
+	}
+
+	return modules, nil
+}
+
+// Detect reads a `go list -m -json all` style module list from r, attaches
+// licence information discovered by classifier (or supplied via overrides),
+// validates it against rules, and groups the result into a dependency.List.
+//
+// New integrations should prefer DetectModules, which runs `go list` itself
+// instead of requiring a pre-generated deps.json; Detect remains for
+// callers that already produce that file out-of-band.
+func Detect(r io.Reader, classifier *Classifier, rules *Rules, overrides dependency.Overrides, includeIndirect bool) (*dependency.List, error) {
+	modules, err := DecodeModules(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildDependencyList(modules, classifier, rules, overrides, includeIndirect)
+}
+
+// DetectionError reports the dependencies buildDependencyList could not
+// place directly into a dependency.List: those it found no confident
+// licence for, and those whose licence was confidently identified but
+// rejected by the rules. Detect and DetectModules return one of these
+// alongside the partial dependency.List they still managed to build, so
+// that every problem dependency surfaces in a single pass instead of being
+// fixed one `go generate` cycle at a time.
+type DetectionError struct {
+	// Unresolved holds dependencies for which no licence could be
+	// confidently classified, whether from a discovered file or an
+	// override.
+	Unresolved []dependency.Info
+	// Disallowed holds dependencies whose confidently classified licence
+	// did not satisfy the rules.
+	Disallowed []DisallowedDependency
+}
+
+// DisallowedDependency pairs a dependency with the reason rules.Validate
+// rejected its licences.
+type DisallowedDependency struct {
+	dependency.Info
+	Reason error
+}
+
+func (e *DetectionError) Error() string {
+	parts := make([]string, 0, len(e.Unresolved)+len(e.Disallowed))
+	for _, d := range e.Unresolved {
+		parts = append(parts, fmt.Sprintf("%s: no licence could be confidently determined", d.Name))
+	}
+	for _, d := range e.Disallowed {
+		parts = append(parts, fmt.Sprintf("%s: %s", d.Name, d.Reason))
+	}
+	return fmt.Sprintf("%d unresolved, %d disallowed dependencies: %s", len(e.Unresolved), len(e.Disallowed), strings.Join(parts, "; "))
+}
+
+func buildDependencyList(modules []Module, classifier *Classifier, rules *Rules, overrides dependency.Overrides, includeIndirect bool) (*dependency.List, error) {
+	deps := &dependency.List{}
+	var detErr DetectionError
+
+	for _, m := range modules {
+		if m.Main {
+			continue
+		}
+		if m.Indirect && !includeIndirect {
+			continue
+		}
+
+		override := lookupOverride(overrides, m.Path)
+
+		info := dependency.Info{
+			Name:        m.Path,
+			Version:     m.Version,
+			VersionTime: m.Time,
+			Dir:         m.Dir,
+			URL:         determineURL(override.URL, m.Path),
+		}
+
+		// m.Dir is empty for modules go list reports without extracting
+		// locally (e.g. indirect dependencies only needed to build another
+		// module's tests). There's no licence file to scan; fall through
+		// and let any override settle it, otherwise it lands in Unresolved.
+		var licenceFile string
+		if m.Dir != "" {
+			var err error
+			licenceFile, err = findLicenceFile(m.Dir)
+			if err != nil {
+				return nil, fmt.Errorf("scanning %s for a licence file: %w", m.Path, err)
+			}
+		}
+		if licenceFile != "" {
+			matches, err := classifyFile(classifier, licenceFile, override.ConfidenceFloor)
+			if err != nil {
+				return nil, fmt.Errorf("classifying licence for %s: %w", m.Path, err)
+			}
+			if len(matches) > 0 {
+				info.LicenceFile = licenceFile
+				info.Licences = matches
+				info.LicenceType = matches[0].SPDXID
+			}
+		}
+
+		switch {
+		case len(override.Licences) > 0:
+			info.LicenceFile = ""
+			info.Licences = override.Licences
+			info.LicenceType = override.Licences[0].SPDXID
+		case override.LicenceText != "":
+			matches := classifier.IdentifyAll([]byte(override.LicenceText), override.ConfidenceFloor)
+			info.LicenceFile = ""
+			info.Licences = matches
+			if len(matches) > 0 {
+				info.LicenceType = matches[0].SPDXID
+			}
+		case override.LicenceFile != "":
+			licenceFile := filepath.Join(m.Dir, override.LicenceFile)
+			matches, err := classifyFile(classifier, licenceFile, override.ConfidenceFloor)
+			if err != nil {
+				return nil, fmt.Errorf("classifying overridden licence file for %s: %w", m.Path, err)
+			}
+			info.LicenceFile = licenceFile
+			info.Licences = matches
+			if len(matches) > 0 {
+				info.LicenceType = matches[0].SPDXID
+			}
+		case override.LicenceType != "":
+			info.Licences = []dependency.LicenceMatch{{SPDXID: override.LicenceType, Confidence: 1}}
+			info.LicenceType = override.LicenceType
+		}
+
+		if len(info.Licences) == 0 {
+			detErr.Unresolved = append(detErr.Unresolved, info)
+			continue
+		}
+
+		if err := rules.Validate(info.Licences); err != nil {
+			detErr.Disallowed = append(detErr.Disallowed, DisallowedDependency{Info: info, Reason: err})
+			continue
+		}
+
+		if m.Indirect {
+			deps.Indirect = append(deps.Indirect, info)
+		} else {
+			deps.Direct = append(deps.Direct, info)
+		}
+	}
+
+	if len(detErr.Unresolved) > 0 || len(detErr.Disallowed) > 0 {
+		return deps, &detErr
+	}
+
+	return deps, nil
+}
+
+// lookupOverride returns the override configured for path: an exact match
+// takes priority, falling back to the longest "<prefix>/*" entry whose
+// prefix path contains it.
+func lookupOverride(overrides dependency.Overrides, path string) dependency.Info {
+	if exact, ok := overrides[path]; ok {
+		return exact
+	}
+
+	var best string
+	for key := range overrides {
+		prefix, ok := strings.CutSuffix(key, "/*")
+		if !ok {
+			continue
+		}
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			if len(prefix) > len(best) {
+				best = prefix
+			}
+		}
+	}
+
+	if best == "" {
+		return dependency.Info{}
+	}
+	return overrides[best+"/*"]
+}
+
+func classifyFile(classifier *Classifier, path string, floor float64) ([]dependency.LicenceMatch, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return classifier.IdentifyAll(content, floor), nil
+}
+
+func findLicenceFile(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		stem := strings.ToLower(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())))
+		for _, candidate := range licenceFileStems {
+			if stem == candidate {
+				return filepath.Join(dir, entry.Name()), nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// determineURL resolves the web address used to link to a dependency,
+// preferring an explicit override and otherwise deriving a best guess from
+// the module path.
+func determineURL(override, modPath string) string {
+	if override != "" {
+		return override
+	}
+
+	if rest, ok := strings.CutPrefix(modPath, "k8s.io/"); ok {
+		return "https://github.com/kubernetes/" + rest
+	}
+
+	if match := githubPathPattern.FindString(modPath); match != "" {
+		return "https://" + match
+	}
+
+	return "https://" + modPath
+}
@@ -19,6 +19,7 @@ package detector
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -32,6 +33,7 @@ func TestDetect(t *testing.T) {
 		overrides        dependency.Overrides
 		wantDependencies func() *dependency.List
 		wantErr          bool
+		wantDetectionErr func(t *testing.T, err *DetectionError)
 	}{
 		{
 			name:            "All",
@@ -81,6 +83,41 @@ func TestDetect(t *testing.T) {
 					d := d
 					if d.Name == "github.com/russross/blackfriday/v2" {
 						d.LicenceType = "MIT"
+						d.Licences = []dependency.LicenceMatch{{SPDXID: "MIT", Confidence: 1}}
+					}
+					deps.Direct = append(deps.Direct, d)
+				}
+
+				return deps
+			},
+		},
+		{
+			name:            "WithMultiLicenceOverride",
+			includeIndirect: true,
+			overrides: map[string]dependency.Info{
+				"github.com/russross/blackfriday/v2": {
+					Name: "github.com/russross/blackfriday/v2",
+					Licences: []dependency.LicenceMatch{
+						{SPDXID: "MIT", Confidence: 0.97},
+						{SPDXID: "Apache-2.0", Confidence: 0.95},
+					},
+				},
+				"github.com/gorhill/cronexpr": {Name: "github.com/gorhill/cronexpr", LicenceType: "GPL-3.0"},
+			},
+			wantDependencies: func() *dependency.List {
+				deps := &dependency.List{
+					Indirect: mkIndirectDeps(),
+				}
+
+				for _, d := range mkDirectDeps() {
+					d := d
+					if d.Name == "github.com/russross/blackfriday/v2" {
+						d.LicenceType = "MIT"
+						d.LicenceFile = ""
+						d.Licences = []dependency.LicenceMatch{
+							{SPDXID: "MIT", Confidence: 0.97},
+							{SPDXID: "Apache-2.0", Confidence: 0.95},
+						}
 					}
 					deps.Direct = append(deps.Direct, d)
 				}
@@ -110,16 +147,133 @@ func TestDetect(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			// cronexpr's GPLv3 file isn't a stem findLicenceFile recognises
+			// on its own, so the override supplies the licence body inline
+			// instead of naming a file, and classification runs against it
+			// the same way it would against a file on disk.
+			name:            "WithLicenceTextOverride",
+			includeIndirect: true,
+			overrides: map[string]dependency.Info{
+				"github.com/gorhill/cronexpr": {
+					Name:        "github.com/gorhill/cronexpr",
+					LicenceText: mustReadFile(t, "testdata/github.com/dgryski/go-minhash@v0.0.0-20170608043002-7fe510aff544/licence"),
+				},
+			},
+			wantDependencies: func() *dependency.List {
+				deps := &dependency.List{Indirect: mkIndirectDeps()}
+
+				for _, d := range mkDirectDeps() {
+					d := d
+					if d.Name == "github.com/gorhill/cronexpr" {
+						d.LicenceType = "MIT"
+						d.LicenceFile = ""
+						d.Licences = []dependency.LicenceMatch{{SPDXID: "MIT", Confidence: 1, Offset: 51, Extent: 988}}
+					}
+					deps.Direct = append(deps.Direct, d)
+				}
 
+				return deps
+			},
+		},
 		{
+			// go-spew's own licence file classifies as ISC at ~0.97
+			// confidence; raising ConfidenceFloor above that suppresses the
+			// match entirely, leaving the dependency unresolved rather than
+			// silently accepting a lower-confidence guess.
+			name:            "WithConfidenceFloorOverride",
+			includeIndirect: true,
+			overrides: map[string]dependency.Info{
+				"github.com/davecgh/go-spew":  {Name: "github.com/davecgh/go-spew", ConfidenceFloor: 0.99},
+				"github.com/gorhill/cronexpr": {Name: "github.com/gorhill/cronexpr", LicenceType: "GPL-3.0"},
+			},
+			wantErr: true,
+			wantDetectionErr: func(t *testing.T, err *DetectionError) {
+				require.Len(t, err.Unresolved, 1)
+				require.Equal(t, "github.com/davecgh/go-spew", err.Unresolved[0].Name)
+				require.Empty(t, err.Disallowed)
+			},
+		},
+		{
+			// No exact override is configured for cronexpr; it instead
+			// falls back to the "github.com/gorhill/*" prefix default.
+			name:            "WithPrefixDefaultOverride",
+			includeIndirect: true,
+			overrides: map[string]dependency.Info{
+				"github.com/gorhill/*": {LicenceType: "GPL-3.0"},
+			},
+			wantDependencies: func() *dependency.List {
+				return &dependency.List{
+					Indirect: mkIndirectDeps(),
+					Direct:   mkDirectDeps(),
+				}
+			},
+		},
+
+		{
+			// cronexpr carries no override here, so its licence file (a
+			// stem findLicenceFile doesn't recognise) goes unclassified;
+			// go-spew's override names a licence the rules reject. Both
+			// land in the DetectionError rather than aborting the whole
+			// run, and blackfriday still resolves successfully alongside
+			// them.
 			name:            "LicenceNotAllowed",
 			includeIndirect: true,
 			overrides: map[string]dependency.Info{
 				"github.com/davecgh/go-spew":         {Name: "github.com/davecgh/go-spew", LicenceType: "Totally Legit License 2.0"},
 				"github.com/russross/blackfriday/v2": {Name: "github.com/russross/blackfriday/v2", LicenceType: "MIT"},
-				"github.com/davecgh/go-gk":           {Name: "github.com/davecgh/go-spew", LicenceType: "UNKNOWN"},
 			},
 			wantErr: true,
+			wantDetectionErr: func(t *testing.T, err *DetectionError) {
+				require.Len(t, err.Unresolved, 1)
+				require.Equal(t, "github.com/gorhill/cronexpr", err.Unresolved[0].Name)
+
+				require.Len(t, err.Disallowed, 1)
+				require.Equal(t, "github.com/davecgh/go-spew", err.Disallowed[0].Name)
+				require.ErrorIs(t, err.Disallowed[0].Reason, ErrLicenceNotAllowed)
+			},
+		},
+		{
+			name:            "LicenceNotAllowedUnlessAnyMatchSatisfiesRules",
+			includeIndirect: true,
+			overrides: map[string]dependency.Info{
+				"github.com/davecgh/go-spew": {
+					Name: "github.com/davecgh/go-spew",
+					Licences: []dependency.LicenceMatch{
+						{SPDXID: "Totally Legit License 2.0", Confidence: 0.99},
+						{SPDXID: "ISC", Confidence: 0.92},
+					},
+				},
+				"github.com/russross/blackfriday/v2": {Name: "github.com/russross/blackfriday/v2", LicenceType: "MIT"},
+				"github.com/gorhill/cronexpr":        {Name: "github.com/gorhill/cronexpr", LicenceType: "GPL-3.0"},
+			},
+			wantDependencies: func() *dependency.List {
+				deps := &dependency.List{}
+
+				for _, d := range mkIndirectDeps() {
+					d := d
+					if d.Name == "github.com/davecgh/go-spew" {
+						d.LicenceType = "Totally Legit License 2.0"
+						d.LicenceFile = ""
+						d.Licences = []dependency.LicenceMatch{
+							{SPDXID: "Totally Legit License 2.0", Confidence: 0.99},
+							{SPDXID: "ISC", Confidence: 0.92},
+						}
+					}
+					deps.Indirect = append(deps.Indirect, d)
+				}
+
+				for _, d := range mkDirectDeps() {
+					d := d
+					if d.Name == "github.com/russross/blackfriday/v2" {
+						d.LicenceType = "MIT"
+						d.Licences = []dependency.LicenceMatch{{SPDXID: "MIT", Confidence: 1}}
+					}
+					deps.Direct = append(deps.Direct, d)
+				}
+
+				return deps
+			},
 		},
 	}
 
@@ -137,6 +291,12 @@ func TestDetect(t *testing.T) {
 			require.NoError(t, err)
 
 			gotDependencies, err := Detect(f, classifier, rules, tc.overrides, tc.includeIndirect)
+			if tc.wantDetectionErr != nil {
+				var detErr *DetectionError
+				require.ErrorAs(t, err, &detErr)
+				tc.wantDetectionErr(t, detErr)
+				return
+			}
 			if tc.wantErr {
 				require.Error(t, err)
 				return
@@ -148,6 +308,98 @@ func TestDetect(t *testing.T) {
 	}
 }
 
+// mustReadFile reads path and fails the test immediately if it can't,
+// letting table-driven test cases inline a testdata file's contents as an
+// override.LicenceText value.
+func mustReadFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestIdentifyAllCoalescesRepeatedSPDXID(t *testing.T) {
+	classifier, err := NewClassifier("")
+	require.NoError(t, err)
+
+	// The real licenseclassifier LICENSE restates its Apache-2.0 body in a
+	// trailing "APPENDIX: How to apply" section, a common shape for
+	// Apache-2.0 files in the wild. That must surface as a single licence,
+	// not as a spurious dual-licensed match.
+	matches := classifier.IdentifyAll([]byte(mustReadFile(t, "testdata/apache2-with-appendix.txt")), 0)
+
+	var apacheMatches int
+	for _, m := range matches {
+		if m.SPDXID == "Apache-2.0" {
+			apacheMatches++
+		}
+	}
+	require.Equal(t, 1, apacheMatches)
+}
+
+func TestDetectSkipsModulesWithoutADir(t *testing.T) {
+	classifier, err := NewClassifier("")
+	require.NoError(t, err)
+
+	rules, err := LoadRules("testdata/rules.json")
+	require.NoError(t, err)
+
+	modules := `[{"Path":"go.elastic.co/go-licence-detector","Main":true},` +
+		`{"Path":"example.com/no-local-dir","Version":"v1.0.0"}]`
+
+	deps, err := Detect(strings.NewReader(modules), classifier, rules, nil, false)
+
+	var detErr *DetectionError
+	require.ErrorAs(t, err, &detErr)
+	require.Len(t, detErr.Unresolved, 1)
+	require.Equal(t, "example.com/no-local-dir", detErr.Unresolved[0].Name)
+	require.Empty(t, deps.Direct)
+	require.Empty(t, deps.Indirect)
+}
+
+func TestRulesValidate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		rules   Rules
+		matches []dependency.LicenceMatch
+		wantErr bool
+	}{
+		{
+			name:    "DualLicensedWithOneDenied",
+			rules:   Rules{Denied: []string{"GPL-3.0"}},
+			matches: []dependency.LicenceMatch{{SPDXID: "MIT"}, {SPDXID: "GPL-3.0"}},
+		},
+		{
+			name:    "AllMatchesDenied",
+			rules:   Rules{Denied: []string{"GPL-3.0"}},
+			matches: []dependency.LicenceMatch{{SPDXID: "GPL-3.0"}},
+			wantErr: true,
+		},
+		{
+			name:    "DualLicensedWithOnlyDeniedOnAllowed",
+			rules:   Rules{Allowed: []string{"MIT"}, Denied: []string{"GPL-3.0"}},
+			matches: []dependency.LicenceMatch{{SPDXID: "GPL-3.0"}, {SPDXID: "Apache-2.0"}},
+			wantErr: true,
+		},
+		{
+			name:    "DualLicensedWithAllowedAlternative",
+			rules:   Rules{Allowed: []string{"MIT"}, Denied: []string{"GPL-3.0"}},
+			matches: []dependency.LicenceMatch{{SPDXID: "GPL-3.0"}, {SPDXID: "MIT"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.rules.Validate(tc.matches)
+			if tc.wantErr {
+				require.ErrorIs(t, err, ErrLicenceNotAllowed)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
 func mkIndirectDeps() []dependency.Info {
 	return []dependency.Info{
 		{
@@ -158,6 +410,7 @@ func mkIndirectDeps() []dependency.Info {
 			LicenceType: "ISC",
 			LicenceFile: "testdata/github.com/davecgh/go-spew@v1.1.0/LICENCE.txt",
 			URL:         "https://github.com/davecgh/go-spew",
+			Licences:    []dependency.LicenceMatch{{SPDXID: "ISC", Confidence: 0.9705449189985272, Offset: 63, Extent: 679}},
 		},
 		{
 			Name:        "github.com/dgryski/go-minhash",
@@ -167,6 +420,7 @@ func mkIndirectDeps() []dependency.Info {
 			LicenceType: "MIT",
 			LicenceFile: "testdata/github.com/dgryski/go-minhash@v0.0.0-20170608043002-7fe510aff544/licence",
 			URL:         "https://github.com/dgryski/go-minhash",
+			Licences:    []dependency.LicenceMatch{{SPDXID: "MIT", Confidence: 1, Offset: 51, Extent: 988}},
 		},
 		{
 			Name:        "github.com/dgryski/go-spooky",
@@ -176,6 +430,7 @@ func mkIndirectDeps() []dependency.Info {
 			LicenceType: "MIT",
 			LicenceFile: "testdata/github.com/dgryski/go-spooky@v0.0.0-20170606183049-ed3d087f40e2/COPYING",
 			URL:         "https://github.com/dgryski/go-spooky",
+			Licences:    []dependency.LicenceMatch{{SPDXID: "MIT", Confidence: 1, Offset: 51, Extent: 988}},
 		},
 	}
 }
@@ -190,6 +445,7 @@ func mkDirectDeps() []dependency.Info {
 			LicenceType: "MIT",
 			LicenceFile: "testdata/github.com/ekzhu/minhash-lsh@v0.0.0-20171225071031-5c06ee8586a1/licence.txt",
 			URL:         "https://github.com/ekzhu/minhash-lsh",
+			Licences:    []dependency.LicenceMatch{{SPDXID: "MIT", Confidence: 1, Offset: 51, Extent: 988}},
 		},
 		{
 			Name:        "github.com/russross/blackfriday/v2",
@@ -199,6 +455,7 @@ func mkDirectDeps() []dependency.Info {
 			LicenceType: "BSD-2-Clause",
 			LicenceFile: "testdata/github.com/russross/blackfriday/v2@v2.0.1/LICENSE.rst",
 			URL:         "https://github.com/russross/blackfriday",
+			Licences:    []dependency.LicenceMatch{{SPDXID: "BSD-2-Clause", Confidence: 1, Offset: 107, Extent: 1197}},
 		},
 		{
 			Name:        "github.com/gorhill/cronexpr",
@@ -208,6 +465,7 @@ func mkDirectDeps() []dependency.Info {
 			LicenceType: "GPL-3.0",
 			LicenceFile: "",
 			URL:         "https://github.com/gorhill/cronexpr",
+			Licences:    []dependency.LicenceMatch{{SPDXID: "GPL-3.0", Confidence: 1}},
 		},
 	}
 }
@@ -222,6 +480,7 @@ func mkDirectOverridenDeps() []dependency.Info {
 			LicenceType: "MIT",
 			LicenceFile: "testdata/github.com/ekzhu/minhash-lsh@v0.0.0-20171225071031-5c06ee8586a1/licence.txt",
 			URL:         "https://github.com/ekzhu/minhash-lsh",
+			Licences:    []dependency.LicenceMatch{{SPDXID: "MIT", Confidence: 1, Offset: 51, Extent: 988}},
 		},
 		{
 			Name:        "github.com/russross/blackfriday/v2",
@@ -231,6 +490,7 @@ func mkDirectOverridenDeps() []dependency.Info {
 			LicenceType: "BSD-2-Clause",
 			LicenceFile: "testdata/github.com/russross/blackfriday/v2@v2.0.1/LICENSE.rst",
 			URL:         "https://github.com/russross/blackfriday",
+			Licences:    []dependency.LicenceMatch{{SPDXID: "BSD-2-Clause", Confidence: 1, Offset: 107, Extent: 1197}},
 		},
 		{
 			Name:        "github.com/gorhill/cronexpr",
@@ -240,6 +500,7 @@ func mkDirectOverridenDeps() []dependency.Info {
 			LicenceType: "GPL-3.0",
 			LicenceFile: "testdata/github.com/gorhill/cronexpr@v0.0.0-20161205141322-d520615e531a/GPLv3",
 			URL:         "https://github.com/gorhill/cronexpr",
+			Licences:    []dependency.LicenceMatch{{SPDXID: "GPL-3.0", Confidence: 0.9999677720841793, Offset: 0, Extent: 31029}},
 		},
 	}
 }
@@ -286,3 +547,75 @@ func TestDetermineURL(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeModule(t *testing.T) {
+	testCases := []struct {
+		name     string
+		existing Module
+		incoming Module
+		want     Module
+	}{
+		{
+			name:     "IndirectStaysIndirectWhenBothIndirect",
+			existing: Module{Path: "example.com/dep", Indirect: true},
+			incoming: Module{Path: "example.com/dep", Indirect: true},
+			want:     Module{Path: "example.com/dep", Indirect: true},
+		},
+		{
+			name:     "DirectInEitherWorkspaceModuleWins",
+			existing: Module{Path: "example.com/dep", Indirect: false},
+			incoming: Module{Path: "example.com/dep", Indirect: true},
+			want:     Module{Path: "example.com/dep", Indirect: false},
+		},
+		{
+			name:     "ReplaceIsAdoptedFromIncoming",
+			existing: Module{Path: "example.com/dep", Version: "v1.0.0", Indirect: false},
+			incoming: Module{Path: "example.com/dep", Version: "v1.0.0", Indirect: true, Replace: &Module{Path: "example.com/dep", Version: "v1.0.1-fork"}},
+			want:     Module{Path: "example.com/dep", Version: "v1.0.0", Indirect: false, Replace: &Module{Path: "example.com/dep", Version: "v1.0.1-fork"}},
+		},
+		{
+			name:     "ReplaceFromAnIndirectEntryDoesNotLoseEarlierDirect",
+			existing: Module{Path: "example.com/dep", Version: "v1.0.0", Indirect: false},
+			incoming: Module{Path: "example.com/dep", Version: "v1.0.1", Indirect: true, Replace: &Module{Path: "example.com/dep", Version: "v1.0.1-fork"}},
+			want:     Module{Path: "example.com/dep", Version: "v1.0.1", Indirect: false, Replace: &Module{Path: "example.com/dep", Version: "v1.0.1-fork"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			have := mergeModule(tc.existing, tc.incoming)
+			require.Equal(t, tc.want, have)
+		})
+	}
+}
+
+func TestParseWorkUse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "SingleLineDirectives",
+			content: "go 1.21\n\nuse ./a\nuse ./b\n",
+			want:    []string{"./a", "./b"},
+		},
+		{
+			name:    "ParenthesisedBlock",
+			content: "go 1.21\n\nuse (\n\t./a\n\t./b\n)\n",
+			want:    []string{"./a", "./b"},
+		},
+		{
+			name:    "MixOfBothFormsAndComments",
+			content: "go 1.21\n\n// a standalone module\nuse ./a\nuse (\n\t./b\n\t// a commented-out module\n\t./c\n)\n",
+			want:    []string{"./a", "./b", "./c"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			have := parseWorkUse([]byte(tc.content))
+			require.Equal(t, tc.want, have)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package licensedb embeds a pre-serialized github.com/google/licenseclassifier
+// licence archive. The vendored licenseclassifier module only embeds the raw
+// licenses/*.txt corpus, not the licenses.db archive its own New() expects by
+// default, so licenses.db here was generated from that corpus with the
+// module's own tools/license_serializer and is committed so the classifier
+// has known licences to load without a build-time dependency on the upstream
+// module shipping one.
+package licensedb
+
+import _ "embed"
+
+//go:embed licenses.db
+var archive []byte
+
+// Archive returns the serialized licence archive bytes, suitable for
+// licenseclassifier.ArchiveBytes.
+func Archive() []byte {
+	return archive
+}
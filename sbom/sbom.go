@@ -0,0 +1,189 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package sbom renders a dependency.List as a CycloneDX software bill of
+// materials.
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.elastic.co/go-licence-detector/dependency"
+)
+
+const (
+	bomFormat   = "CycloneDX"
+	specVersion = "1.5"
+	bomVersion  = 1
+)
+
+// BOM is the root of a CycloneDX document.
+type BOM struct {
+	BOMFormat   string      `json:"bomFormat"`
+	SpecVersion string      `json:"specVersion"`
+	Version     int         `json:"version"`
+	Metadata    Metadata    `json:"metadata"`
+	Components  []Component `json:"components"`
+}
+
+// Metadata carries the SBOM-level information, including the component the
+// SBOM describes.
+type Metadata struct {
+	Timestamp string     `json:"timestamp"`
+	Component *Component `json:"component,omitempty"`
+}
+
+// Component is a CycloneDX component, used for both the root module and its
+// dependencies.
+type Component struct {
+	Type               string              `json:"type"`
+	BOMRef             string              `json:"bom-ref"`
+	Group              string              `json:"group,omitempty"`
+	Name               string              `json:"name"`
+	Version            string              `json:"version,omitempty"`
+	PackageURL         string              `json:"purl,omitempty"`
+	ExternalReferences []ExternalReference `json:"externalReferences,omitempty"`
+	Licenses           []LicenceChoice     `json:"licenses,omitempty"`
+	Properties         []Property          `json:"properties,omitempty"`
+}
+
+// ExternalReference points at further information about a component.
+type ExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// LicenceChoice is a single entry in a component's licenses array.
+type LicenceChoice struct {
+	License License `json:"license"`
+}
+
+// License identifies a licence either by SPDX id or, when that isn't
+// possible, by free text name.
+type License struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// Property is a free-form name/value pair attached to a component.
+type Property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// WriteCycloneDX renders deps as a CycloneDX 1.5 JSON document.
+func WriteCycloneDX(w io.Writer, deps *dependency.List) error {
+	bom := BOM{
+		BOMFormat:   bomFormat,
+		SpecVersion: specVersion,
+		Version:     bomVersion,
+		Metadata: Metadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Component: toComponentPtr(deps.Module),
+		},
+	}
+
+	for _, info := range deps.Direct {
+		bom.Components = append(bom.Components, toComponent(info))
+	}
+	for _, info := range deps.Indirect {
+		bom.Components = append(bom.Components, toComponent(info))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(bom); err != nil {
+		return fmt.Errorf("encoding CycloneDX document: %w", err)
+	}
+
+	return nil
+}
+
+func toComponentPtr(info dependency.Info) *Component {
+	if info.Name == "" {
+		return nil
+	}
+	c := toComponent(info)
+	return &c
+}
+
+func toComponent(info dependency.Info) Component {
+	group, name := splitModulePath(info.Name)
+	purl := "pkg:golang/" + info.Name
+	if info.Version != "" {
+		purl += "@" + info.Version
+	}
+
+	c := Component{
+		Type:       "library",
+		BOMRef:     purl,
+		Group:      group,
+		Name:       name,
+		Version:    info.Version,
+		PackageURL: purl,
+	}
+
+	if info.VersionTime != "" {
+		c.Properties = append(c.Properties, Property{Name: "versionTime", Value: info.VersionTime})
+	}
+
+	if info.URL != "" {
+		c.ExternalReferences = append(c.ExternalReferences, ExternalReference{Type: "vcs", URL: info.URL})
+	}
+	if info.Name != "" && info.Version != "" {
+		c.ExternalReferences = append(c.ExternalReferences, ExternalReference{
+			Type: "website",
+			URL:  fmt.Sprintf("https://pkg.go.dev/%s@%s", info.Name, info.Version),
+		})
+	}
+
+	switch {
+	case len(info.Licences) > 0:
+		c.Licenses = make([]LicenceChoice, len(info.Licences))
+		for i, m := range info.Licences {
+			c.Licenses[i] = LicenceChoice{License: toLicence(m.SPDXID)}
+		}
+	case info.LicenceType != "":
+		c.Licenses = []LicenceChoice{{License: toLicence(info.LicenceType)}}
+	}
+
+	return c
+}
+
+// toLicence renders a licence identifier as an SPDX id when it looks like
+// one, falling back to free text for the cases the classifier couldn't map
+// to the SPDX list.
+func toLicence(licenceType string) License {
+	if dependency.LooksLikeSPDXID(licenceType) {
+		return License{ID: licenceType}
+	}
+	return License{Name: licenceType}
+}
+
+// splitModulePath splits a module path into the CycloneDX group/name pair,
+// e.g. "github.com/gorhill/cronexpr" -> ("github.com/gorhill", "cronexpr").
+func splitModulePath(modPath string) (group, name string) {
+	idx := strings.LastIndex(modPath, "/")
+	if idx < 0 {
+		return "", modPath
+	}
+	return modPath[:idx], modPath[idx+1:]
+}
@@ -0,0 +1,183 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sbom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.elastic.co/go-licence-detector/dependency"
+)
+
+func TestSplitModulePath(t *testing.T) {
+	testCases := []struct {
+		name      string
+		modPath   string
+		wantGroup string
+		wantName  string
+	}{
+		{
+			name:      "GitHubPath",
+			modPath:   "github.com/gorhill/cronexpr",
+			wantGroup: "github.com/gorhill",
+			wantName:  "cronexpr",
+		},
+		{
+			name:      "NestedPath",
+			modPath:   "go.elastic.co/go-licence-detector",
+			wantGroup: "go.elastic.co",
+			wantName:  "go-licence-detector",
+		},
+		{
+			name:     "NoSlash",
+			modPath:  "rsc.io",
+			wantName: "rsc.io",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			group, name := splitModulePath(tc.modPath)
+			require.Equal(t, tc.wantGroup, group)
+			require.Equal(t, tc.wantName, name)
+		})
+	}
+}
+
+func TestToComponent(t *testing.T) {
+	testCases := []struct {
+		name string
+		info dependency.Info
+		want Component
+	}{
+		{
+			name: "SingleSPDXLicence",
+			info: dependency.Info{
+				Name:    "github.com/gorhill/cronexpr",
+				Version: "v1.0.0",
+				Licences: []dependency.LicenceMatch{
+					{SPDXID: "Apache-2.0", Confidence: 1},
+				},
+			},
+			want: Component{
+				Type:       "library",
+				BOMRef:     "pkg:golang/github.com/gorhill/cronexpr@v1.0.0",
+				Group:      "github.com/gorhill",
+				Name:       "cronexpr",
+				Version:    "v1.0.0",
+				PackageURL: "pkg:golang/github.com/gorhill/cronexpr@v1.0.0",
+				ExternalReferences: []ExternalReference{
+					{Type: "website", URL: "https://pkg.go.dev/github.com/gorhill/cronexpr@v1.0.0"},
+				},
+				Licenses: []LicenceChoice{{License: License{ID: "Apache-2.0"}}},
+			},
+		},
+		{
+			name: "MultipleLicencesEachBecomeTheirOwnEntry",
+			info: dependency.Info{
+				Name:    "github.com/russross/blackfriday/v2",
+				Version: "v2.0.1",
+				Licences: []dependency.LicenceMatch{
+					{SPDXID: "MIT", Confidence: 0.97},
+					{SPDXID: "Apache-2.0", Confidence: 0.95},
+				},
+			},
+			want: Component{
+				Type:       "library",
+				BOMRef:     "pkg:golang/github.com/russross/blackfriday/v2@v2.0.1",
+				Group:      "github.com/russross/blackfriday",
+				Name:       "v2",
+				Version:    "v2.0.1",
+				PackageURL: "pkg:golang/github.com/russross/blackfriday/v2@v2.0.1",
+				ExternalReferences: []ExternalReference{
+					{Type: "website", URL: "https://pkg.go.dev/github.com/russross/blackfriday/v2@v2.0.1"},
+				},
+				Licenses: []LicenceChoice{
+					{License: License{ID: "MIT"}},
+					{License: License{ID: "Apache-2.0"}},
+				},
+			},
+		},
+		{
+			name: "FreeTextLicenceFallsBackToName",
+			info: dependency.Info{
+				Name:    "github.com/davecgh/go-spew",
+				Version: "v1.1.0",
+				Licences: []dependency.LicenceMatch{
+					{SPDXID: "Totally Legit License 2.0", Confidence: 0.99},
+				},
+			},
+			want: Component{
+				Type:       "library",
+				BOMRef:     "pkg:golang/github.com/davecgh/go-spew@v1.1.0",
+				Group:      "github.com/davecgh",
+				Name:       "go-spew",
+				Version:    "v1.1.0",
+				PackageURL: "pkg:golang/github.com/davecgh/go-spew@v1.1.0",
+				ExternalReferences: []ExternalReference{
+					{Type: "website", URL: "https://pkg.go.dev/github.com/davecgh/go-spew@v1.1.0"},
+				},
+				Licenses: []LicenceChoice{{License: License{Name: "Totally Legit License 2.0"}}},
+			},
+		},
+		{
+			name: "VersionTimeAndURLAreSurfacedAsPropertiesAndReferences",
+			info: dependency.Info{
+				Name:        "github.com/gorhill/cronexpr",
+				Version:     "v1.0.0",
+				VersionTime: "2016-12-05T14:13:22Z",
+				URL:         "https://github.com/gorhill/cronexpr",
+			},
+			want: Component{
+				Type:       "library",
+				BOMRef:     "pkg:golang/github.com/gorhill/cronexpr@v1.0.0",
+				Group:      "github.com/gorhill",
+				Name:       "cronexpr",
+				Version:    "v1.0.0",
+				PackageURL: "pkg:golang/github.com/gorhill/cronexpr@v1.0.0",
+				ExternalReferences: []ExternalReference{
+					{Type: "vcs", URL: "https://github.com/gorhill/cronexpr"},
+					{Type: "website", URL: "https://pkg.go.dev/github.com/gorhill/cronexpr@v1.0.0"},
+				},
+				Properties: []Property{{Name: "versionTime", Value: "2016-12-05T14:13:22Z"}},
+			},
+		},
+		{
+			// The consuming module itself (deps.Module) routinely has no
+			// Version, since `go list -m -json` never reports one for the
+			// main module; the purl/bom-ref must not gain a dangling "@".
+			name: "EmptyVersionOmitsAtSuffixAndWebsiteReference",
+			info: dependency.Info{
+				Name: "go.elastic.co/go-licence-detector",
+			},
+			want: Component{
+				Type:       "library",
+				BOMRef:     "pkg:golang/go.elastic.co/go-licence-detector",
+				Group:      "go.elastic.co",
+				Name:       "go-licence-detector",
+				PackageURL: "pkg:golang/go.elastic.co/go-licence-detector",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, toComponent(tc.info))
+		})
+	}
+}
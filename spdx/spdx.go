@@ -0,0 +1,498 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package spdx renders a dependency.List as an SPDX 2.3 document, in either
+// its tag:value or JSON serialisation.
+package spdx
+
+import (
+	"crypto/sha1" //nolint:gosec // used only to derive stable, non-secret element ids
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.elastic.co/go-licence-detector/dependency"
+)
+
+// Format selects the SPDX serialisation WriteSPDX produces.
+type Format int
+
+// Supported serialisations.
+const (
+	FormatTagValue Format = iota
+	FormatJSON
+)
+
+// Options configures document-level metadata that isn't derivable from the
+// dependency.List itself.
+type Options struct {
+	Format            Format
+	DocumentName      string
+	DocumentNamespace string
+}
+
+const (
+	spdxVersion = "SPDX-2.3"
+	dataLicense = "CC0-1.0"
+	noAssertion = "NOASSERTION"
+	documentID  = "SPDXRef-DOCUMENT"
+	creatorTool = "Tool: go-licence-detector"
+)
+
+// document is the serialisation-agnostic in-memory representation shared by
+// both the tag:value and JSON renderers.
+type document struct {
+	Name              string
+	Namespace         string
+	Created           string
+	RootPackage       pkg
+	Packages          []pkg
+	Relationships     []relationship
+	ExtractedLicences []extractedLicensingInfo
+}
+
+type pkg struct {
+	SPDXID               string
+	Name                 string
+	Version              string
+	DownloadLocation     string
+	LicenseConcluded     string
+	LicenseDeclared      string
+	LicenseInfoFromFiles []string
+	Checksum             string
+}
+
+type relationship struct {
+	ElementID        string
+	RelationshipType string
+	RelatedElement   string
+}
+
+type extractedLicensingInfo struct {
+	LicenseID     string
+	Name          string
+	ExtractedText string
+}
+
+// WriteSPDX renders deps as an SPDX 2.3 document in the serialisation
+// selected by opts.Format.
+func WriteSPDX(w io.Writer, deps *dependency.List, opts Options) error {
+	doc, err := buildDocument(deps, opts)
+	if err != nil {
+		return err
+	}
+
+	switch opts.Format {
+	case FormatJSON:
+		return writeJSON(w, doc)
+	default:
+		return writeTagValue(w, doc)
+	}
+}
+
+func buildDocument(deps *dependency.List, opts Options) (document, error) {
+	name := opts.DocumentName
+	if name == "" {
+		name = deps.Module.Name
+	}
+	namespace := opts.DocumentNamespace
+	if namespace == "" {
+		namespace = fmt.Sprintf("https://spdx.org/spdxdocs/%s-%s", sanitiseID(name), packageID(deps.Module.Name, deps.Module.Version))
+	}
+
+	doc := document{
+		Name:      name,
+		Namespace: namespace,
+		Created:   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	root, err := toPackage(deps.Module)
+	if err != nil {
+		return document{}, err
+	}
+	doc.RootPackage = root
+
+	extracted := map[string]extractedLicensingInfo{}
+
+	all := append(append([]dependency.Info{}, deps.Direct...), deps.Indirect...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+
+	for _, info := range all {
+		p, err := toPackage(info)
+		if err != nil {
+			return document{}, err
+		}
+		doc.Packages = append(doc.Packages, p)
+
+		doc.Relationships = append(doc.Relationships, relationship{
+			ElementID:        root.SPDXID,
+			RelationshipType: "DEPENDS_ON",
+			RelatedElement:   p.SPDXID,
+		})
+
+		for _, licenceType := range licenceTypes(info) {
+			if dependency.LooksLikeSPDXID(licenceType) {
+				continue
+			}
+			ref := licenceRef(licenceType)
+			if _, ok := extracted[ref]; !ok {
+				extracted[ref] = extractedLicensingInfo{
+					LicenseID:     ref,
+					Name:          licenceType,
+					ExtractedText: licenceText(info),
+				}
+			}
+		}
+	}
+
+	for _, ref := range sortedKeys(extracted) {
+		doc.ExtractedLicences = append(doc.ExtractedLicences, extracted[ref])
+	}
+
+	return doc, nil
+}
+
+func toPackage(info dependency.Info) (pkg, error) {
+	downloadLocation := noAssertion
+	if info.URL != "" {
+		downloadLocation = info.URL
+	}
+
+	licence := licenceExpression(info)
+
+	licenceFiles := []string{noAssertion}
+	if info.LicenceFile != "" {
+		licenceFiles = []string{info.LicenceFile}
+	}
+
+	checksum, err := packageChecksum(info.Dir)
+	if err != nil {
+		return pkg{}, fmt.Errorf("checksumming %s: %w", info.Name, err)
+	}
+
+	return pkg{
+		SPDXID:               packageID(info.Name, info.Version),
+		Name:                 info.Name,
+		Version:              info.Version,
+		DownloadLocation:     downloadLocation,
+		LicenseConcluded:     licence,
+		LicenseDeclared:      licence,
+		LicenseInfoFromFiles: licenceFiles,
+		Checksum:             checksum,
+	}, nil
+}
+
+// packageID derives a stable SPDX element id from a module's path@version.
+func packageID(modPath, version string) string {
+	sum := sha1.Sum([]byte(modPath + "@" + version)) //nolint:gosec // non-cryptographic use
+	return "SPDXRef-Package-" + hex.EncodeToString(sum[:])[:12]
+}
+
+// licenceRef derives a stable LicenseRef id for free-text licences the
+// classifier couldn't map to an SPDX id.
+func licenceRef(licenceType string) string {
+	sum := sha1.Sum([]byte(licenceType)) //nolint:gosec // non-cryptographic use
+	return "LicenseRef-" + hex.EncodeToString(sum[:])[:12]
+}
+
+// licenceTypes lists the licence identifiers that apply to info: every
+// ranked match in Licences, or the single LicenceType for dependencies
+// detected (or overridden) before multi-licence support existed.
+func licenceTypes(info dependency.Info) []string {
+	if len(info.Licences) == 0 {
+		if info.LicenceType == "" {
+			return nil
+		}
+		return []string{info.LicenceType}
+	}
+	types := make([]string, len(info.Licences))
+	for i, m := range info.Licences {
+		types[i] = m.SPDXID
+	}
+	return types
+}
+
+// licenceExpression renders info's licence(s) as an SPDX licence expression,
+// combining a dual- (or multi-) licensed dependency's alternatives with the
+// disjunctive "OR" operator, as SPDX recommends for PackageLicenseConcluded
+// and PackageLicenseDeclared.
+func licenceExpression(info dependency.Info) string {
+	types := licenceTypes(info)
+	if len(types) == 0 {
+		return noAssertion
+	}
+
+	ids := make([]string, len(types))
+	for i, t := range types {
+		if dependency.LooksLikeSPDXID(t) {
+			ids[i] = t
+		} else {
+			ids[i] = licenceRef(t)
+		}
+	}
+	if len(ids) == 1 {
+		return ids[0]
+	}
+	return "(" + strings.Join(ids, " OR ") + ")"
+}
+
+func licenceText(info dependency.Info) string {
+	if info.LicenceFile == "" {
+		return info.LicenceType
+	}
+	content, err := os.ReadFile(info.LicenceFile)
+	if err != nil {
+		return info.LicenceType
+	}
+	return string(content)
+}
+
+// packageChecksum hashes a module's zip archive when one is cached by the
+// module system, falling back to the concatenation of every regular file
+// under dir when it isn't (e.g. a replaced or vendored module).
+func packageChecksum(dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+
+	if zipPath, version, ok := findModuleZip(dir); ok {
+		content, err := os.ReadFile(zipPath)
+		if err == nil {
+			return sha256Hex(content), nil
+		}
+		_ = version // module cache layout only; fall through to hashing Dir on any error
+	}
+
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		h.Write(content)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// findModuleZip locates the cached download zip for the extracted module
+// directory dir, following the `$GOPATH/pkg/mod/cache/download` layout `go
+// mod download` leaves behind: an extracted tree at
+// .../pkg/mod/<module>@<version> has its zip at
+// .../pkg/mod/cache/download/<module>/@v/<version>.zip.
+func findModuleZip(dir string) (path string, version string, ok bool) {
+	modRoot := string(filepath.Separator) + "mod" + string(filepath.Separator)
+	idx := strings.LastIndex(dir, modRoot)
+	if idx < 0 {
+		return "", "", false
+	}
+	cacheRoot := dir[:idx+len(modRoot)]
+	modPath, version, ok := strings.Cut(dir[idx+len(modRoot):], "@")
+	if !ok {
+		return "", "", false
+	}
+
+	zipPath := filepath.Join(cacheRoot, "cache", "download", modPath, "@v", version+".zip")
+	if _, err := os.Stat(zipPath); err != nil {
+		return "", "", false
+	}
+	return zipPath, version, true
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func sanitiseID(s string) string {
+	s = strings.ReplaceAll(s, "/", "-")
+	return strings.ReplaceAll(s, " ", "-")
+}
+
+func sortedKeys(m map[string]extractedLicensingInfo) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeJSON(w io.Writer, doc document) error {
+	type jsonPackage struct {
+		SPDXID                  string   `json:"SPDXID"`
+		Name                    string   `json:"name"`
+		VersionInfo             string   `json:"versionInfo,omitempty"`
+		DownloadLocation        string   `json:"downloadLocation"`
+		LicenseConcluded        string   `json:"licenseConcluded"`
+		LicenseDeclared         string   `json:"licenseDeclared"`
+		LicenseInfoFromFiles    []string `json:"licenseInfoFromFiles,omitempty"`
+		CopyrightText           string   `json:"copyrightText"`
+		PackageVerificationCode string   `json:"checksumSHA256,omitempty"`
+	}
+	type jsonRelationship struct {
+		SPDXElementID      string `json:"spdxElementId"`
+		RelationshipType   string `json:"relationshipType"`
+		RelatedSPDXElement string `json:"relatedSpdxElement"`
+	}
+	type jsonExtractedLicensingInfo struct {
+		LicenseID     string `json:"licenseId"`
+		Name          string `json:"name,omitempty"`
+		ExtractedText string `json:"extractedText"`
+	}
+	type jsonCreationInfo struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	}
+	type jsonDocument struct {
+		SPDXVersion                string                       `json:"spdxVersion"`
+		DataLicense                string                       `json:"dataLicense"`
+		SPDXID                     string                       `json:"SPDXID"`
+		Name                       string                       `json:"name"`
+		DocumentNamespace          string                       `json:"documentNamespace"`
+		CreationInfo               jsonCreationInfo             `json:"creationInfo"`
+		Packages                   []jsonPackage                `json:"packages"`
+		Relationships              []jsonRelationship           `json:"relationships"`
+		HasExtractedLicensingInfos []jsonExtractedLicensingInfo `json:"hasExtractedLicensingInfos,omitempty"`
+	}
+
+	toJSONPackage := func(p pkg) jsonPackage {
+		return jsonPackage{
+			SPDXID:                  p.SPDXID,
+			Name:                    p.Name,
+			VersionInfo:             p.Version,
+			DownloadLocation:        p.DownloadLocation,
+			LicenseConcluded:        p.LicenseConcluded,
+			LicenseDeclared:         p.LicenseDeclared,
+			LicenseInfoFromFiles:    p.LicenseInfoFromFiles,
+			CopyrightText:           noAssertion,
+			PackageVerificationCode: p.Checksum,
+		}
+	}
+
+	out := jsonDocument{
+		SPDXVersion:       spdxVersion,
+		DataLicense:       dataLicense,
+		SPDXID:            documentID,
+		Name:              doc.Name,
+		DocumentNamespace: doc.Namespace,
+		CreationInfo: jsonCreationInfo{
+			Created:  doc.Created,
+			Creators: []string{creatorTool},
+		},
+		Packages: []jsonPackage{toJSONPackage(doc.RootPackage)},
+	}
+	for _, p := range doc.Packages {
+		out.Packages = append(out.Packages, toJSONPackage(p))
+	}
+	out.Relationships = append(out.Relationships, jsonRelationship{
+		SPDXElementID:      documentID,
+		RelationshipType:   "DESCRIBES",
+		RelatedSPDXElement: doc.RootPackage.SPDXID,
+	})
+	for _, r := range doc.Relationships {
+		out.Relationships = append(out.Relationships, jsonRelationship{
+			SPDXElementID:      r.ElementID,
+			RelationshipType:   r.RelationshipType,
+			RelatedSPDXElement: r.RelatedElement,
+		})
+	}
+	for _, e := range doc.ExtractedLicences {
+		out.HasExtractedLicensingInfos = append(out.HasExtractedLicensingInfos, jsonExtractedLicensingInfo{
+			LicenseID:     e.LicenseID,
+			Name:          e.Name,
+			ExtractedText: e.ExtractedText,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("encoding SPDX JSON document: %w", err)
+	}
+	return nil
+}
+
+func writeTagValue(w io.Writer, doc document) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "SPDXVersion: %s\n", spdxVersion)
+	fmt.Fprintf(&b, "DataLicense: %s\n", dataLicense)
+	fmt.Fprintf(&b, "SPDXID: %s\n", documentID)
+	fmt.Fprintf(&b, "DocumentName: %s\n", doc.Name)
+	fmt.Fprintf(&b, "DocumentNamespace: %s\n", doc.Namespace)
+	fmt.Fprintf(&b, "Creator: %s\n", creatorTool)
+	fmt.Fprintf(&b, "Created: %s\n\n", doc.Created)
+
+	writePackage(&b, doc.RootPackage)
+	fmt.Fprintf(&b, "Relationship: %s DESCRIBES %s\n\n", documentID, doc.RootPackage.SPDXID)
+
+	for _, p := range doc.Packages {
+		writePackage(&b, p)
+	}
+
+	for _, r := range doc.Relationships {
+		fmt.Fprintf(&b, "Relationship: %s %s %s\n", r.ElementID, r.RelationshipType, r.RelatedElement)
+	}
+
+	for _, e := range doc.ExtractedLicences {
+		fmt.Fprintf(&b, "\nLicenseID: %s\n", e.LicenseID)
+		fmt.Fprintf(&b, "ExtractedText: <text>%s</text>\n", e.ExtractedText)
+		if e.Name != "" {
+			fmt.Fprintf(&b, "LicenseName: %s\n", e.Name)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writePackage(b *strings.Builder, p pkg) {
+	fmt.Fprintf(b, "PackageName: %s\n", p.Name)
+	fmt.Fprintf(b, "SPDXID: %s\n", p.SPDXID)
+	if p.Version != "" {
+		fmt.Fprintf(b, "PackageVersion: %s\n", p.Version)
+	}
+	fmt.Fprintf(b, "PackageDownloadLocation: %s\n", p.DownloadLocation)
+	if p.Checksum != "" {
+		fmt.Fprintf(b, "PackageChecksum: SHA256: %s\n", p.Checksum)
+	}
+	fmt.Fprintf(b, "PackageLicenseConcluded: %s\n", p.LicenseConcluded)
+	fmt.Fprintf(b, "PackageLicenseDeclared: %s\n", p.LicenseDeclared)
+	for _, f := range p.LicenseInfoFromFiles {
+		fmt.Fprintf(b, "PackageLicenseInfoFromFiles: %s\n", f)
+	}
+	fmt.Fprintf(b, "PackageCopyrightText: %s\n\n", noAssertion)
+}
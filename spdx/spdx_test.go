@@ -0,0 +1,99 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spdx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.elastic.co/go-licence-detector/dependency"
+)
+
+func TestLicenceExpression(t *testing.T) {
+	testCases := []struct {
+		name string
+		info dependency.Info
+		want string
+	}{
+		{
+			name: "NoLicences",
+			info: dependency.Info{},
+			want: noAssertion,
+		},
+		{
+			name: "SingleSPDXLicence",
+			info: dependency.Info{Licences: []dependency.LicenceMatch{{SPDXID: "Apache-2.0"}}},
+			want: "Apache-2.0",
+		},
+		{
+			name: "MultipleLicencesAreCombinedWithOR",
+			info: dependency.Info{Licences: []dependency.LicenceMatch{
+				{SPDXID: "MIT"},
+				{SPDXID: "Apache-2.0"},
+			}},
+			want: "(MIT OR Apache-2.0)",
+		},
+		{
+			name: "FreeTextLicenceFallsBackToLicenceType",
+			info: dependency.Info{LicenceType: "Totally Legit License 2.0"},
+			want: licenceRef("Totally Legit License 2.0"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, licenceExpression(tc.info))
+		})
+	}
+}
+
+func TestLicenceRefAndPackageIDAreStableAndDistinct(t *testing.T) {
+	require.Equal(t, licenceRef("Totally Legit License 2.0"), licenceRef("Totally Legit License 2.0"))
+	require.NotEqual(t, licenceRef("Totally Legit License 2.0"), licenceRef("Another Licence"))
+	require.True(t, len(licenceRef("Totally Legit License 2.0")) > len("LicenseRef-"))
+
+	require.Equal(t, packageID("github.com/gorhill/cronexpr", "v1.0.0"), packageID("github.com/gorhill/cronexpr", "v1.0.0"))
+	require.NotEqual(t, packageID("github.com/gorhill/cronexpr", "v1.0.0"), packageID("github.com/gorhill/cronexpr", "v1.0.1"))
+}
+
+func TestPackageChecksum(t *testing.T) {
+	t.Run("EmptyDirYieldsEmptyChecksum", func(t *testing.T) {
+		checksum, err := packageChecksum("")
+		require.NoError(t, err)
+		require.Empty(t, checksum)
+	})
+
+	t.Run("FallsBackToHashingDirContentsWhenNoModuleZipIsCached", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello world"), 0o644))
+
+		want := sha256.Sum256([]byte("hello world"))
+
+		checksum, err := packageChecksum(dir)
+		require.NoError(t, err)
+		require.Equal(t, hex.EncodeToString(want[:]), checksum)
+	})
+}
+
+func TestSanitiseID(t *testing.T) {
+	require.Equal(t, "go.elastic.co-go-licence-detector", sanitiseID("go.elastic.co/go licence-detector"))
+}